@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/cmd/cosign/cli/attest"
+	"github.com/sigstore/cosign/cmd/cosign/cli/options"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcioroots"
+	"github.com/sigstore/cosign/pkg/oci"
+	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
+	sigs "github.com/sigstore/cosign/pkg/signature"
+)
+
+// Shorthand predicate type names accepted in AttestOptions.PredicateType.
+// AttestationRequirement.PredicateType is matched against the full predicate
+// type URI recorded in the in-toto statement, which these names never equal.
+const (
+	PredicateSLSAProvenance = "slsaprovenance1"
+	PredicateSPDX           = "spdx"
+	PredicateCycloneDX      = "cyclonedx"
+	PredicateVuln           = "vuln"
+)
+
+// AttestOptions configures attaching an in-toto attestation to a pushed
+// collection.
+type AttestOptions struct {
+	// Destination is the collection's registry reference (the same one it
+	// was pushed to).
+	Destination string
+	// PredicatePath is a path to the predicate document to attach, e.g. a
+	// SLSA provenance file, SPDX SBOM, or CycloneDX SBOM.
+	PredicatePath string
+	// PredicateType identifies the predicate, e.g. PredicateSLSAProvenance.
+	PredicateType string
+	// Signing configures how the attestation envelope is signed, reusing the
+	// same keyless/key/KMS modes as signCollection.
+	Signing SigningOptions
+
+	// Configs, PlainHTTP and Insecure mirror PushOptions for registry access.
+	Configs   []string
+	PlainHTTP bool
+	Insecure  bool
+}
+
+// AttestationRequirement describes one attestation a pull policy requires:
+// at least Count attestations of PredicateType, signed by an identity
+// matching Identity (and, if set, issued by Issuer). A Count of zero is
+// treated as one.
+type AttestationRequirement struct {
+	PredicateType string
+	Identity      string
+	Issuer        string
+	Count         int
+}
+
+// attachAttestation wraps the predicate at o.PredicatePath as a DSSE-enveloped
+// in-toto statement bound to o.Destination, signs it using o.Signing, and
+// stores it as an OCI referrer manifest on the collection's manifest digest.
+func attachAttestation(ctx context.Context, o *AttestOptions) error {
+	if o.PredicateType == "" {
+		return fmt.Errorf("predicate type is required")
+	}
+
+	so := o.Signing
+	if so.Mode == "" {
+		so.Mode = SigningModeKeyless
+	}
+
+	ko := options.KeyOpts{
+		RekorURL:     so.rekorURL(),
+		OIDCClientID: so.oidcClientID(),
+		OIDCIssuer:   so.oidcIssuer(),
+		FulcioURL:    so.fulcioURL(),
+	}
+
+	switch so.Mode {
+	case SigningModeKey, SigningModeKMS:
+		if so.KeyRef == "" {
+			return fmt.Errorf("signing mode %q requires a key reference", so.Mode)
+		}
+		ko.KeyRef = so.KeyRef
+	case SigningModeKeyless:
+		token, err := so.identityToken()
+		if err != nil {
+			return err
+		}
+		ko.IDToken = token
+		// Required by sigstore / cosign for keyless signing at the time of writing
+		os.Setenv("COSIGN_EXPERIMENTAL", "1")
+	default:
+		return fmt.Errorf("unsupported signing mode %q", so.Mode)
+	}
+
+	kc, err := buildKeychain(o.Configs)
+	if err != nil {
+		return err
+	}
+	regopts := options.RegistryOptions{Keychain: kc}
+	if o.PlainHTTP || o.Insecure {
+		regopts.AllowInsecure = true
+	}
+
+	c := attest.AttestCommand{
+		KeyOpts:         ko,
+		RegistryOptions: regopts,
+		PredicatePath:   o.PredicatePath,
+		PredicateType:   o.PredicateType,
+		Timeout:         100 * time.Second,
+	}
+	if err := c.Exec(ctx, o.Destination); err != nil {
+		return fmt.Errorf("attesting %s: %w", o.Destination, err)
+	}
+	return nil
+}
+
+// attestationStatement is the subset of a DSSE-enveloped in-toto statement
+// verifyAttestations needs to match it against an AttestationRequirement.
+type attestationStatement struct {
+	PredicateType string `json:"predicateType"`
+}
+
+// dsseEnvelope is the subset of a DSSE envelope's fields needed to recover
+// the enclosed in-toto statement from a verified attestation's raw payload.
+type dsseEnvelope struct {
+	Payload string `json:"payload"`
+}
+
+// verifyAttestations verifies every attestation attached to o.Source and
+// tallies, per AttestationRequirement, how many verified attestations
+// actually match its predicate type and signer identity/issuer, failing if
+// any requirement's Count isn't met by real matches (not merely "at least
+// one attestation of any kind verified").
+func verifyAttestations(ctx context.Context, o *PullOptions, policy []AttestationRequirement) error {
+	if len(policy) == 0 {
+		return nil
+	}
+
+	so := o.Signing
+	if so.Mode == "" {
+		so.Mode = SigningModeKeyless
+	}
+
+	ref, err := name.ParseReference(o.Source)
+	if err != nil {
+		return err
+	}
+
+	kc, err := buildKeychain(o.Configs)
+	if err != nil {
+		return err
+	}
+	remoteOpts := []ociremote.Option{ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(kc))}
+
+	signedImg, err := ociremote.SignedImage(ref, remoteOpts...)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", o.Source, err)
+	}
+
+	co := &cosign.CheckOpts{IgnoreSCT: true}
+	switch so.Mode {
+	case SigningModeKey, SigningModeKMS:
+		if so.KeyRef == "" {
+			return fmt.Errorf("verification mode %q requires a key reference", so.Mode)
+		}
+		sv, err := sigs.PublicKeyFromKeyRef(ctx, so.KeyRef)
+		if err != nil {
+			return fmt.Errorf("loading verification key %s: %w", so.KeyRef, err)
+		}
+		co.SigVerifier = sv
+	case SigningModeKeyless:
+		os.Setenv("COSIGN_EXPERIMENTAL", "1")
+		rekorClient, err := cosign.NewClient(so.rekorURL())
+		if err != nil {
+			return fmt.Errorf("connecting to Rekor at %s: %w", so.rekorURL(), err)
+		}
+		co.RekorClient = rekorClient
+		co.RootCerts = fulcioroots.Get()
+	default:
+		return fmt.Errorf("unsupported signing mode %q", so.Mode)
+	}
+
+	verified, _, err := cosign.VerifyImageAttestations(ctx, signedImg, co)
+	if err != nil {
+		return fmt.Errorf("verifying attestations for %s: %w", o.Source, err)
+	}
+
+	counts := make([]int, len(policy))
+	for _, att := range verified {
+		stmt, identity, issuer, err := decodeAttestation(att)
+		if err != nil {
+			// Not every verified DSSE envelope need be a statement this
+			// policy cares about; skip ones we can't decode rather than
+			// failing the whole verification.
+			continue
+		}
+		for i, req := range policy {
+			if req.PredicateType != "" && req.PredicateType != stmt.PredicateType {
+				continue
+			}
+			if req.Identity != "" && req.Identity != identity {
+				continue
+			}
+			if req.Issuer != "" && req.Issuer != issuer {
+				continue
+			}
+			counts[i]++
+		}
+	}
+
+	for i, req := range policy {
+		want := req.Count
+		if want == 0 {
+			want = 1
+		}
+		if counts[i] < want {
+			return fmt.Errorf("policy requires %d attestation(s) of type %q from identity %q issuer %q, found %d",
+				want, req.PredicateType, req.Identity, req.Issuer, counts[i])
+		}
+	}
+	return nil
+}
+
+// decodeAttestation recovers the in-toto statement and signer identity from
+// one verified attestation's DSSE envelope.
+func decodeAttestation(att oci.Signature) (attestationStatement, string, string, error) {
+	payload, err := att.Payload()
+	if err != nil {
+		return attestationStatement{}, "", "", fmt.Errorf("reading attestation payload: %w", err)
+	}
+
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return attestationStatement{}, "", "", fmt.Errorf("parsing DSSE envelope: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return attestationStatement{}, "", "", fmt.Errorf("decoding DSSE payload: %w", err)
+	}
+
+	var stmt attestationStatement
+	if err := json.Unmarshal(decoded, &stmt); err != nil {
+		return attestationStatement{}, "", "", fmt.Errorf("parsing in-toto statement: %w", err)
+	}
+
+	var identity, issuer string
+	if cert, _ := att.Cert(); cert != nil {
+		issuer = certOIDCIssuer(cert)
+		switch {
+		case len(cert.URIs) > 0:
+			identity = cert.URIs[0].String()
+		case len(cert.EmailAddresses) > 0:
+			identity = cert.EmailAddresses[0]
+		}
+	}
+
+	return stmt, identity, issuer, nil
+}
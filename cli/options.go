@@ -0,0 +1,48 @@
+package cli
+
+// PushOptions configures a push of a UOR Collection to a registry.
+type PushOptions struct {
+	// Source is the local path or reference being pushed.
+	Source string
+	// Destination is the registry reference the collection is pushed to.
+	Destination string
+	// Configs is a list of docker config JSON paths to source credentials from.
+	Configs []string
+	// PlainHTTP allows connecting to the registry over plain HTTP.
+	PlainHTTP bool
+	// Insecure allows connecting to registries with an untrusted TLS certificate.
+	Insecure bool
+	// LogLevel controls the verbosity of the signing/push pipeline.
+	LogLevel string
+	// Signing configures how (and whether) the pushed collection is signed.
+	Signing SigningOptions
+}
+
+// PullOptions configures a pull of a UOR Collection from a registry.
+type PullOptions struct {
+	// Source is the registry reference being pulled.
+	Source string
+	// Configs is a list of docker config JSON paths to source credentials from.
+	Configs []string
+	// PlainHTTP allows connecting to the registry over plain HTTP.
+	PlainHTTP bool
+	// Insecure allows connecting to registries with an untrusted TLS certificate.
+	Insecure bool
+	// LogLevel controls the verbosity of the verification pipeline.
+	LogLevel string
+	// Signing configures signature verification, including trust roots and
+	// certificate identity constraints.
+	Signing SigningOptions
+
+	// Offline, when set, verifies against a cosign attestation bundle stored
+	// alongside the collection instead of contacting Rekor and Fulcio live.
+	// Requirements listed in Attestations are checked online regardless.
+	Offline bool
+	// BundlePath, when set, reads the cosign bundle from this local path
+	// instead of fetching it from the registry.
+	BundlePath string
+
+	// Attestations, when set, requires the pulled collection to carry at
+	// least the listed in-toto attestations before verifyCollection succeeds.
+	Attestations []AttestationRequirement
+}
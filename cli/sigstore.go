@@ -4,51 +4,201 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"sync"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/docker/cli/cli/config/configfile"
-	"github.com/docker/cli/cli/config/credentials"
-	"github.com/docker/cli/cli/config/types"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sigstore/cosign/cmd/cosign/cli/options"
 	"github.com/sigstore/cosign/cmd/cosign/cli/sign"
-	"github.com/sigstore/cosign/cmd/cosign/cli/verify"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/cosign/fulcioroots"
+	"github.com/sigstore/cosign/pkg/oci"
+	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
+	sigs "github.com/sigstore/cosign/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/tuf"
+
+	"github.com/adrezni/uor-client-go/pkg/registryauth"
 
 	// Loads OIDC providers
 	_ "github.com/sigstore/cosign/pkg/providers/all"
 )
 
-// Sign applies keyless OIDC signatures to sign UOR Collections
+// SigningMode selects which signing or verification backend signCollection
+// and verifyCollection use.
+type SigningMode string
+
+const (
+	// SigningModeKeyless performs OIDC keyless signing/verification against
+	// Fulcio/Rekor, the historical default.
+	SigningModeKeyless SigningMode = "keyless"
+	// SigningModeKey signs/verifies with a cosign key pair read from disk.
+	SigningModeKey SigningMode = "key"
+	// SigningModeKMS signs/verifies with a key reference resolved by a KMS
+	// provider, e.g. "awskms://", "gcpkms://", "hashivault://", or a PKCS#11 URI.
+	SigningModeKMS SigningMode = "kms"
+)
+
+// SigningOptions configures how signCollection and verifyCollection reach
+// Sigstore infrastructure, which signing backend they use, and (for
+// verification) which certificate identities are acceptable.
+type SigningOptions struct {
+	// Mode selects the signing/verification backend. Defaults to
+	// SigningModeKeyless if empty.
+	Mode SigningMode
+
+	// KeyRef is the key reference used when Mode is SigningModeKey (a path to
+	// a cosign key pair) or SigningModeKMS (a KMS or PKCS#11 URI).
+	KeyRef string
+
+	// FulcioURL overrides the Fulcio CA used for keyless signing/verification.
+	FulcioURL string
+	// RekorURL overrides the Rekor transparency log used for keyless
+	// signing/verification.
+	RekorURL string
+	// OIDCIssuer overrides the OIDC issuer used to request an identity token
+	// for keyless signing.
+	OIDCIssuer string
+	// OIDCClientID overrides the OIDC client ID used for keyless signing.
+	OIDCClientID string
+
+	// TUFRootPath, if set, loads a TUF trust root from this path instead of
+	// the public Sigstore TUF root, for private Sigstore deployments.
+	TUFRootPath string
+
+	// IdentityToken is an OIDC identity token to use directly for keyless
+	// signing, e.g. one minted by a CI provider. Takes precedence over
+	// IdentityTokenFile, IdentityTokenEnv, and interactive OIDC flows.
+	IdentityToken string
+	// IdentityTokenFile is a path to a file containing an OIDC identity
+	// token, read when IdentityToken is empty.
+	IdentityTokenFile string
+	// IdentityTokenEnv is the name of an environment variable containing an
+	// OIDC identity token, read when IdentityToken and IdentityTokenFile are
+	// both empty -- the common way CI systems like GitHub Actions and GitLab
+	// inject a short-lived token without it ever touching disk.
+	IdentityTokenEnv string
+
+	// CertIdentity restricts verification to certificates whose SAN matches
+	// this value exactly, e.g. a GitHub Actions workflow ref.
+	CertIdentity string
+	// CertIdentityRegexp restricts verification to certificates whose SAN
+	// matches this regular expression.
+	CertIdentityRegexp string
+	// CertOIDCIssuer restricts verification to certificates issued against
+	// this OIDC issuer, e.g. "https://token.actions.githubusercontent.com".
+	CertOIDCIssuer string
+}
+
+// rekorURL returns the configured Rekor URL, falling back to the public
+// Sigstore instance.
+func (s SigningOptions) rekorURL() string {
+	if s.RekorURL != "" {
+		return s.RekorURL
+	}
+	return "https://rekor.sigstore.dev"
+}
+
+// fulcioURL returns the configured Fulcio URL, falling back to the public
+// Sigstore instance.
+func (s SigningOptions) fulcioURL() string {
+	if s.FulcioURL != "" {
+		return s.FulcioURL
+	}
+	return "https://fulcio.sigstore.dev"
+}
+
+// oidcIssuer returns the configured OIDC issuer, falling back to the public
+// Sigstore instance.
+func (s SigningOptions) oidcIssuer() string {
+	if s.OIDCIssuer != "" {
+		return s.OIDCIssuer
+	}
+	return "https://oauth2.sigstore.dev/auth"
+}
+
+// oidcClientID returns the configured OIDC client ID, falling back to the
+// public Sigstore default.
+func (s SigningOptions) oidcClientID() string {
+	if s.OIDCClientID != "" {
+		return s.OIDCClientID
+	}
+	return "sigstore"
+}
+
+// identityToken resolves the OIDC identity token to use for keyless signing,
+// preferring an inline token, then one read from a file, then one read from
+// an environment variable.
+func (s SigningOptions) identityToken() (string, error) {
+	if s.IdentityToken != "" {
+		return s.IdentityToken, nil
+	}
+	if s.IdentityTokenFile != "" {
+		b, err := os.ReadFile(s.IdentityTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading OIDC identity token from %s: %w", s.IdentityTokenFile, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	if s.IdentityTokenEnv != "" {
+		return strings.TrimSpace(os.Getenv(s.IdentityTokenEnv)), nil
+	}
+	return "", nil
+}
+
+// Sign signs UOR Collections using keyless OIDC, a cosign key pair, or a KMS
+// reference, depending on o.Signing.Mode.
 func signCollection(ctx context.Context, o *PushOptions) error {
+	so := o.Signing
+	if so.Mode == "" {
+		so.Mode = SigningModeKeyless
+	}
 
 	ko := options.KeyOpts{
-		RekorURL:        "https://rekor.sigstore.dev",
-		OIDCClientID:    "sigstore",
+		RekorURL:        so.rekorURL(),
+		OIDCClientID:    so.oidcClientID(),
 		OIDCRedirectURL: "",
-		OIDCIssuer:      "https://oauth2.sigstore.dev/auth",
-		FulcioURL:       "https://fulcio.sigstore.dev",
+		OIDCIssuer:      so.oidcIssuer(),
+		FulcioURL:       so.fulcioURL(),
 	}
 
-	// Required by sigstore / cosign for keyless signing at the time of writing
-	os.Setenv("COSIGN_EXPERIMENTAL", "1")
+	switch so.Mode {
+	case SigningModeKey, SigningModeKMS:
+		if so.KeyRef == "" {
+			return fmt.Errorf("signing mode %q requires a key reference", so.Mode)
+		}
+		ko.KeyRef = so.KeyRef
+	case SigningModeKeyless:
+		token, err := so.identityToken()
+		if err != nil {
+			return err
+		}
+		ko.IDToken = token
+		// Required by sigstore / cosign for keyless signing at the time of writing
+		os.Setenv("COSIGN_EXPERIMENTAL", "1")
+	default:
+		return fmt.Errorf("unsupported signing mode %q", so.Mode)
+	}
+
+	if so.TUFRootPath != "" {
+		if err := tuf.Initialize(ctx, "", so.TUFRootPath); err != nil {
+			return fmt.Errorf("loading TUF trust root from %s: %w", so.TUFRootPath, err)
+		}
+	}
 
+	kc, err := buildKeychain(o.Configs)
+	if err != nil {
+		return err
+	}
 	regopts := options.RegistryOptions{
-		Keychain: authn.DefaultKeychain,
+		Keychain: kc,
 	}
 	if o.PlainHTTP || o.Insecure {
 		regopts.AllowInsecure = true
 	}
 
-	if len(o.Configs) != 0 {
-		var err error
-		regopts.Keychain, err = buildKeychain(o.Configs)
-		if err != nil {
-			return err
-		}
-	}
-
 	// Note(afflom): Setting this bool doesn't do anything. Regardless of
 	// the boolean's value, the output is always debug. Waiting for
 	// https://github.com/sigstore/cosign/issues/844.
@@ -61,7 +211,7 @@ func signCollection(ctx context.Context, o *PushOptions) error {
 		Verbose: llevel,
 		Timeout: 100 * time.Second,
 	}
-	err := sign.SignCmd(&opts, ko, regopts, map[string]interface{}{},
+	err = sign.SignCmd(&opts, ko, regopts, map[string]interface{}{},
 		[]string{o.Destination}, "", "", true, "", "",
 		"", true, false, "", false)
 	if err != nil {
@@ -71,107 +221,154 @@ func signCollection(ctx context.Context, o *PushOptions) error {
 
 }
 
-// Verify performs signature verification of keyless signatures
-func verifyCollection(o *PullOptions, ctx context.Context) error {
+// Verify performs signature verification using keyless OIDC, a cosign key
+// pair, or a KMS reference, depending on o.Signing.Mode, enforcing any
+// configured certificate identity constraints. If o.Offline is set,
+// verification is performed entirely against a local or registry-stored
+// cosign bundle instead of contacting Rekor and Fulcio live.
+func verifyCollection(o *PullOptions, ctx context.Context) (*VerificationResult, error) {
+	if len(o.Attestations) != 0 {
+		if err := verifyAttestations(ctx, o, o.Attestations); err != nil {
+			return nil, err
+		}
+	}
 
-	regopts := options.RegistryOptions{
-		Keychain: authn.DefaultKeychain,
+	if o.Offline {
+		return verifyCollectionOffline(ctx, o)
 	}
 
-	if o.PlainHTTP || o.Insecure {
-		regopts.AllowInsecure = true
+	return verifyCollectionOnline(ctx, o)
+}
+
+// verifyCollectionOnline verifies a pushed collection's signature against
+// live Fulcio/Rekor (or a cosign key/KMS reference), and populates a
+// VerificationResult from the verified certificate and Rekor bundle, the
+// same as verifyCollectionOffline does for the --offline path.
+func verifyCollectionOnline(ctx context.Context, o *PullOptions) (*VerificationResult, error) {
+	so := o.Signing
+	if so.Mode == "" {
+		so.Mode = SigningModeKeyless
+	}
+
+	if so.TUFRootPath != "" {
+		if err := tuf.Initialize(ctx, "", so.TUFRootPath); err != nil {
+			return nil, fmt.Errorf("loading TUF trust root from %s: %w", so.TUFRootPath, err)
+		}
 	}
 
-	if len(o.Configs) != 0 {
-		var err error
-		regopts.Keychain, err = buildKeychain(o.Configs)
+	ref, err := name.ParseReference(o.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	kc, err := buildKeychain(o.Configs)
+	if err != nil {
+		return nil, err
+	}
+	remoteOpts := []ociremote.Option{ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(kc))}
+
+	signedImg, err := ociremote.SignedImage(ref, remoteOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", o.Source, err)
+	}
+
+	co := &cosign.CheckOpts{IgnoreSCT: true}
+	switch so.Mode {
+	case SigningModeKey, SigningModeKMS:
+		if so.KeyRef == "" {
+			return nil, fmt.Errorf("verification mode %q requires a key reference", so.Mode)
+		}
+		sv, err := sigs.PublicKeyFromKeyRef(ctx, so.KeyRef)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("loading verification key %s: %w", so.KeyRef, err)
 		}
+		co.SigVerifier = sv
+	case SigningModeKeyless:
+		// Required by sigstore / cosign for keyless signing at the time of writing
+		os.Setenv("COSIGN_EXPERIMENTAL", "1")
+		rekorClient, err := cosign.NewClient(so.rekorURL())
+		if err != nil {
+			return nil, fmt.Errorf("connecting to Rekor at %s: %w", so.rekorURL(), err)
+		}
+		co.RekorClient = rekorClient
+		co.RootCerts = fulcioroots.Get()
+	default:
+		return nil, fmt.Errorf("unsupported signing mode %q", so.Mode)
 	}
 
-	v := verify.VerifyCommand{
-		RekorURL:        "https://rekor.sigstore.dev",
-		RegistryOptions: regopts,
+	verified, _, err := cosign.VerifyImageSignatures(ctx, signedImg, co)
+	if err != nil {
+		return nil, err
+	}
+	if len(verified) == 0 {
+		return nil, fmt.Errorf("no valid signatures found for %s", o.Source)
 	}
 
-	// Required by sigstore / cosign for keyless signing at the time of writing
-	os.Setenv("COSIGN_EXPERIMENTAL", "1")
+	result, err := verificationResultFromSignature(verified[0])
+	if err != nil {
+		return nil, err
+	}
 
-	if err := v.Exec(ctx, []string{o.Source}); err != nil {
-		return err
+	if so.CertIdentity != "" && result.CertSubject != so.CertIdentity {
+		return nil, fmt.Errorf("certificate identity %q does not match required identity %q", result.CertSubject, so.CertIdentity)
 	}
-	return nil
+	if so.CertIdentityRegexp != "" {
+		matched, err := regexp.MatchString(so.CertIdentityRegexp, result.CertSubject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate identity regexp %q: %w", so.CertIdentityRegexp, err)
+		}
+		if !matched {
+			return nil, fmt.Errorf("certificate identity %q does not match required pattern %q", result.CertSubject, so.CertIdentityRegexp)
+		}
+	}
+	if so.CertOIDCIssuer != "" && result.CertIssuer != so.CertOIDCIssuer {
+		return nil, fmt.Errorf("certificate issuer %q does not match required issuer %q", result.CertIssuer, so.CertOIDCIssuer)
+	}
+
+	return result, nil
 }
 
-type KeyChainFunc func(authn.Resource) (authn.Authenticator, error)
+// verificationResultFromSignature builds a VerificationResult from a
+// verified cosign signature's certificate and Rekor bundle.
+func verificationResultFromSignature(sig oci.Signature) (*VerificationResult, error) {
+	result := &VerificationResult{}
+
+	cert, err := sig.Cert()
+	if err != nil {
+		return nil, fmt.Errorf("reading signing certificate: %w", err)
+	}
+	if cert != nil {
+		result.CertIssuer = certOIDCIssuer(cert)
+		switch {
+		case len(cert.URIs) > 0:
+			result.CertSubject = cert.URIs[0].String()
+		case len(cert.EmailAddresses) > 0:
+			result.CertSubject = cert.EmailAddresses[0]
+		}
+	}
+
+	b, err := sig.Bundle()
+	if err != nil {
+		return nil, fmt.Errorf("reading Rekor bundle: %w", err)
+	}
+	if b != nil {
+		result.LogIndex = b.Payload.LogIndex
+		result.IntegratedTime = time.Unix(b.Payload.IntegratedTime, 0)
+	}
 
-func (fn KeyChainFunc) Resolve(r authn.Resource) (authn.Authenticator, error) {
-	return fn(r)
+	return result, nil
 }
 
-func buildKeychain(c []string) (authn.Keychain, error) {
-	var keychainFuncs []authn.Keychain
-	var mu sync.Mutex
-	for _, config := range c {
-		fromConfig := KeyChainFunc(func(target authn.Resource) (authn.Authenticator, error) {
-			mu.Lock()
-			defer mu.Unlock()
-			cf := configfile.New(config)
-			if _, err := os.Stat(config); err != nil {
-				if !os.IsNotExist(err) {
-					return nil, err
-				}
-			}
-
-			file, err := os.Open(config)
-			if err != nil {
-				return nil, err
-			}
-			defer file.Close()
-			if err := cf.LoadFromReader(file); err != nil {
-				return nil, err
-			}
-
-			if !cf.ContainsAuth() {
-				cf.CredentialsStore = credentials.DetectDefaultStore(cf.CredentialsStore)
-			}
-
-			// See:
-			// https://github.com/google/ko/issues/90
-			// https://github.com/moby/moby/blob/fc01c2b481097a6057bec3cd1ab2d7b4488c50c4/registry/config.go#L397-L404
-			var cfg, empty types.AuthConfig
-			for _, key := range []string{
-				target.String(),
-				target.RegistryStr(),
-			} {
-				if key == name.DefaultRegistry {
-					key = authn.DefaultAuthKey
-				}
-
-				cfg, err = cf.GetAuthConfig(key)
-				if err != nil {
-					return nil, err
-				}
-				if cfg != empty {
-					break
-				}
-			}
-			if cfg == empty {
-				return authn.Anonymous, nil
-			}
-
-			return authn.FromConfig(authn.AuthConfig{
-				Username:      cfg.Username,
-				Password:      cfg.Password,
-				Auth:          cfg.Auth,
-				IdentityToken: cfg.IdentityToken,
-				RegistryToken: cfg.RegistryToken,
-			}), nil
-
-		})
-		keychainFuncs = append(keychainFuncs, fromConfig)
-
-	}
-	return authn.NewMultiKeychain(keychainFuncs...), nil
+// buildKeychain composes the shared registryauth credential pipeline for the
+// given explicit config files, layered over the ambient Docker/Podman
+// config, native OS credential helpers, and cloud-provider keychains. Pull,
+// Push, signCollection, and verifyCollection all go through this one
+// pipeline rather than constructing their own.
+func buildKeychain(configs []string) (authn.Keychain, error) {
+	return registryauth.New(
+		registryauth.WithConfigFiles(configs),
+		registryauth.WithAmbientDockerConfig(),
+		registryauth.WithCredentialHelpers(),
+		registryauth.WithCloudKeychains(),
+	), nil
 }
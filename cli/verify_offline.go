@@ -0,0 +1,341 @@
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/pkg/cosign/fulcioroots"
+	"github.com/sigstore/sigstore/pkg/tuf"
+)
+
+// rekorPublicKeyTarget is the TUF target name the Sigstore trust root
+// publishes the active Rekor instance's public key under, whether that's
+// the public Sigstore root or a private one loaded via
+// SigningOptions.TUFRootPath.
+const rekorPublicKeyTarget = "rekor.pub"
+
+// referrersArtifactType is the artifact type cosign uses for the signature
+// manifests it attaches as OCI 1.1 referrers.
+const referrersArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+// VerificationResult is the structured outcome of a successful verification,
+// letting callers enforce policy on the identity and transparency-log record
+// that backed the signature rather than treating verification as a single
+// error/nil boolean.
+type VerificationResult struct {
+	// CertSubject is the SAN of the signing certificate, e.g. a GitHub
+	// Actions workflow ref.
+	CertSubject string
+	// CertIssuer is the OIDC issuer that Fulcio recorded on the certificate.
+	CertIssuer string
+	// LogIndex is the Rekor log index of the signature's entry.
+	LogIndex int64
+	// IntegratedTime is when Rekor included the entry in the log.
+	IntegratedTime time.Time
+}
+
+// rekorBundle mirrors the "rekorBundle" object cosign embeds in a signature
+// manifest: a SignedEntryTimestamp (SET) over the canonicalized log entry,
+// plus the log index and integrated time needed to re-derive it.
+type rekorBundle struct {
+	SignedEntryTimestamp string          `json:"SignedEntryTimestamp"`
+	Payload              rekorSETPayload `json:"Payload"`
+}
+
+type rekorSETPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+}
+
+// cosignBundle is the subset of the cosign attestation bundle format that
+// offline verification needs: the signing certificate and the Rekor bundle
+// proving transparency-log inclusion.
+type cosignBundle struct {
+	Base64Signature string      `json:"base64Signature"`
+	Cert            string      `json:"cert"`
+	Chain           string      `json:"chain"`
+	RekorBundle     rekorBundle `json:"rekorBundle"`
+}
+
+// verifyCollectionOffline verifies a pushed collection's signature fully
+// offline: it fetches the cosign bundle (from o.BundlePath, or else the OCI
+// 1.1 referrers API / "sha256-<digest>.sig" fallback tag), validates the
+// Rekor SET against the configured Rekor public key, validates the signing
+// certificate chain against the configured Fulcio root, and checks that the
+// certificate was valid at the log's integrated time.
+func verifyCollectionOffline(ctx context.Context, o *PullOptions) (*VerificationResult, error) {
+	so := o.Signing
+
+	bundle, err := loadBundle(ctx, o)
+	if err != nil {
+		return nil, fmt.Errorf("loading attestation bundle: %w", err)
+	}
+
+	cert, err := parseCertificate(bundle.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing certificate: %w", err)
+	}
+
+	if err := verifyCertChain(ctx, cert, bundle.Chain, so); err != nil {
+		return nil, fmt.Errorf("verifying certificate chain: %w", err)
+	}
+
+	rekorKey, err := rekorPublicKey(ctx, so)
+	if err != nil {
+		return nil, fmt.Errorf("loading Rekor public key: %w", err)
+	}
+
+	if err := verifyRekorSET(bundle.RekorBundle, rekorKey); err != nil {
+		return nil, fmt.Errorf("verifying Rekor SignedEntryTimestamp: %w", err)
+	}
+
+	integratedTime := time.Unix(bundle.RekorBundle.Payload.IntegratedTime, 0)
+	if integratedTime.Before(cert.NotBefore) || integratedTime.After(cert.NotAfter) {
+		return nil, fmt.Errorf("certificate was not valid at log integrated time %s", integratedTime)
+	}
+
+	result := &VerificationResult{
+		CertIssuer:     certOIDCIssuer(cert),
+		LogIndex:       bundle.RekorBundle.Payload.LogIndex,
+		IntegratedTime: integratedTime,
+	}
+	if len(cert.URIs) > 0 {
+		result.CertSubject = cert.URIs[0].String()
+	} else if len(cert.EmailAddresses) > 0 {
+		result.CertSubject = cert.EmailAddresses[0]
+	}
+
+	if so.CertIdentity != "" && result.CertSubject != so.CertIdentity {
+		return nil, fmt.Errorf("certificate identity %q does not match required identity %q", result.CertSubject, so.CertIdentity)
+	}
+	if so.CertIdentityRegexp != "" {
+		matched, err := regexp.MatchString(so.CertIdentityRegexp, result.CertSubject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate identity regexp %q: %w", so.CertIdentityRegexp, err)
+		}
+		if !matched {
+			return nil, fmt.Errorf("certificate identity %q does not match required pattern %q", result.CertSubject, so.CertIdentityRegexp)
+		}
+	}
+	if so.CertOIDCIssuer != "" && result.CertIssuer != so.CertOIDCIssuer {
+		return nil, fmt.Errorf("certificate issuer %q does not match required issuer %q", result.CertIssuer, so.CertOIDCIssuer)
+	}
+
+	return result, nil
+}
+
+// loadBundle reads the cosign bundle from o.BundlePath if set, otherwise
+// fetches it from the registry via the OCI 1.1 referrers API, falling back
+// to the legacy "sha256-<digest>.sig" tag convention.
+func loadBundle(ctx context.Context, o *PullOptions) (*cosignBundle, error) {
+	var raw []byte
+	if o.BundlePath != "" {
+		b, err := os.ReadFile(o.BundlePath)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	} else {
+		b, err := fetchBundleFromRegistry(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+
+	var bundle cosignBundle
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return nil, fmt.Errorf("parsing bundle JSON: %w", err)
+	}
+	return &bundle, nil
+}
+
+// fetchBundleFromRegistry resolves o.Source's manifest digest and fetches
+// its signature bundle, preferring the OCI 1.1 referrers API and falling
+// back to the cosign "sha256-<digest>.sig" tag for registries that don't yet
+// support referrers.
+func fetchBundleFromRegistry(ctx context.Context, o *PullOptions) ([]byte, error) {
+	ref, err := name.ParseReference(o.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := remote.Get(ref, remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", o.Source, err)
+	}
+
+	referrers, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()), remote.WithContext(ctx))
+	if err == nil {
+		manifest, ierr := referrers.IndexManifest()
+		if ierr == nil {
+			for _, m := range manifest.Manifests {
+				if m.ArtifactType == referrersArtifactType {
+					img, lerr := remote.Image(ref.Context().Digest(m.Digest.String()), remote.WithContext(ctx))
+					if lerr != nil {
+						continue
+					}
+					return readFirstLayer(img)
+				}
+			}
+		}
+	}
+
+	tag := ref.Context().Tag(fmt.Sprintf("sha256-%s.sig", desc.Digest.Hex))
+	img, err := remote.Image(tag, remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("fetching fallback signature tag %s: %w", tag, err)
+	}
+	return readFirstLayer(img)
+}
+
+// readFirstLayer returns the uncompressed contents of an image's sole
+// signature-bundle layer.
+func readFirstLayer(img v1.Image) ([]byte, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("signature image has no layers")
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// rekorPublicKey loads the ECDSA public key used to verify
+// SignedEntryTimestamps from the TUF trust root: the public Sigstore root
+// by default, or a private deployment's root when so.TUFRootPath is set.
+func rekorPublicKey(ctx context.Context, so SigningOptions) (*ecdsa.PublicKey, error) {
+	if so.TUFRootPath != "" {
+		if err := tuf.Initialize(ctx, "", so.TUFRootPath); err != nil {
+			return nil, fmt.Errorf("loading TUF trust root from %s: %w", so.TUFRootPath, err)
+		}
+	}
+
+	client, err := tuf.NewFromEnv(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("initializing TUF client: %w", err)
+	}
+	defer client.Close()
+
+	raw, err := client.GetTarget(rekorPublicKeyTarget)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from TUF trust root: %w", rekorPublicKeyTarget, err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM-encoded public key", rekorPublicKeyTarget)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", rekorPublicKeyTarget, err)
+	}
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECDSA public key", rekorPublicKeyTarget)
+	}
+	return ecKey, nil
+}
+
+// verifyRekorSET verifies the SignedEntryTimestamp over the canonicalized
+// entry payload (body, integrated time, log index and log ID) using the
+// Rekor instance's public key.
+func verifyRekorSET(b rekorBundle, rekorKey *ecdsa.PublicKey) error {
+	if rekorKey == nil {
+		return fmt.Errorf("missing Rekor public key")
+	}
+	set, err := base64.StdEncoding.DecodeString(b.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("decoding SignedEntryTimestamp: %w", err)
+	}
+	// Marshalling a map emits its keys in sorted order with no whitespace,
+	// the canonical JSON form the SET is computed over.
+	payload, err := json.Marshal(map[string]interface{}{
+		"body":           b.Payload.Body,
+		"integratedTime": b.Payload.IntegratedTime,
+		"logIndex":       b.Payload.LogIndex,
+		"logID":          b.Payload.LogID,
+	})
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(rekorKey, digest[:], set) {
+		return fmt.Errorf("SignedEntryTimestamp does not verify against configured Rekor key")
+	}
+	return nil
+}
+
+// verifyCertChain validates that cert chains up to a real Fulcio root --
+// the well-known public Sigstore root, or a private deployment's root
+// loaded via so.TUFRootPath the same way rekorPublicKey resolves rekor.pub
+// -- using the bundle's chain PEM only as intermediates. The bundle itself
+// is attacker-controllable, so it must never be trusted to supply its own
+// root of trust.
+func verifyCertChain(ctx context.Context, cert *x509.Certificate, chainPEM string, so SigningOptions) error {
+	if so.TUFRootPath != "" {
+		if err := tuf.Initialize(ctx, "", so.TUFRootPath); err != nil {
+			return fmt.Errorf("loading TUF trust root from %s: %w", so.TUFRootPath, err)
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for block, rest := pem.Decode([]byte(chainPEM)); block != nil; block, rest = pem.Decode(rest) {
+		ica, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(ica)
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         fulcioroots.Get(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		// Fulcio certificates are short-lived; verifyCollectionOffline checks
+		// validity at the log's integrated time, so the chain is checked as of
+		// the certificate's issuance rather than now.
+		CurrentTime: cert.NotBefore,
+	})
+	return err
+}
+
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certOIDCIssuer extracts the Fulcio OIDC issuer extension (1.3.6.1.4.1.57264.1.8)
+// from the signing certificate.
+func certOIDCIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.String() == "1.3.6.1.4.1.57264.1.8" {
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
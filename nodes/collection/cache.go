@@ -0,0 +1,117 @@
+package collection
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache stores fetched manifest bytes keyed by descriptor digest, so that
+// repeated LoadFromManifest calls across a session (or across a resumed
+// traversal) avoid re-fetching content that's already known.
+type Cache interface {
+	Get(digest string) ([]byte, bool)
+	Put(digest string, data []byte)
+}
+
+// lruDiskCache is a bounded in-memory LRU backed by a content-addressed
+// on-disk directory: entries evicted from memory remain retrievable from
+// disk, while the in-memory tier keeps hot digests off the filesystem.
+type lruDiskCache struct {
+	dir        string
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheItem struct {
+	digest string
+	data   []byte
+}
+
+// NewLRUDiskCache returns a Cache that keeps up to maxEntries digests in
+// memory and persists every entry under dir, content-addressed by digest.
+func NewLRUDiskCache(dir string, maxEntries int) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if maxEntries <= 0 {
+		maxEntries = 128
+	}
+	return &lruDiskCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}, nil
+}
+
+func (c *lruDiskCache) diskPath(digest string) string {
+	return filepath.Join(c.dir, digestFilename(digest))
+}
+
+// digestFilename maps a digest like "sha256:abc" to a flat filename safe for
+// every supported filesystem.
+func digestFilename(digest string) string {
+	out := make([]byte, len(digest))
+	for i := 0; i < len(digest); i++ {
+		if digest[i] == ':' {
+			out[i] = '_'
+			continue
+		}
+		out[i] = digest[i]
+	}
+	return string(out)
+}
+
+func (c *lruDiskCache) Get(digest string) ([]byte, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[digest]; ok {
+		c.ll.MoveToFront(el)
+		data := el.Value.(*cacheItem).data
+		c.mu.Unlock()
+		return data, true
+	}
+	c.mu.Unlock()
+
+	data, err := os.ReadFile(c.diskPath(digest))
+	if err != nil {
+		return nil, false
+	}
+	c.promote(digest, data)
+	return data, true
+}
+
+func (c *lruDiskCache) Put(digest string, data []byte) {
+	_ = os.WriteFile(c.diskPath(digest), data, 0o644)
+	c.promote(digest, data)
+}
+
+// promote inserts or refreshes digest at the front of the in-memory LRU,
+// evicting the least recently used entry if over capacity. Eviction only
+// drops the in-memory copy; the on-disk copy is untouched.
+func (c *lruDiskCache) promote(digest string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[digest]; ok {
+		el.Value.(*cacheItem).data = data
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&cacheItem{digest: digest, data: data})
+	c.items[digest] = el
+
+	for c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).digest)
+	}
+}
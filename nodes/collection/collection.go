@@ -0,0 +1,80 @@
+// Package collection models a UOR Collection: a named graph of content
+// addressed nodes discovered by walking an OCI manifest.
+package collection
+
+import (
+	"sync"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Node is a single piece of content discovered while walking a manifest
+// graph, identified by its content digest.
+type Node interface {
+	// ID returns the node's content digest, e.g. "sha256:...".
+	ID() string
+}
+
+// descriptorNode is the Node implementation backed by an OCI descriptor.
+type descriptorNode struct {
+	desc ocispec.Descriptor
+}
+
+func newDescriptorNode(desc ocispec.Descriptor) descriptorNode {
+	return descriptorNode{desc: desc}
+}
+
+func (n descriptorNode) ID() string {
+	return n.desc.Digest.String()
+}
+
+// Descriptor returns the OCI descriptor backing this node.
+func (n descriptorNode) Descriptor() ocispec.Descriptor {
+	return n.desc
+}
+
+// Collection is a named, deduplicated set of Nodes discovered while walking
+// a manifest graph via LoadFromManifest.
+type Collection struct {
+	name string
+
+	mu    sync.Mutex
+	nodes map[string]Node
+}
+
+// New creates an empty Collection with the given name.
+func New(name string) *Collection {
+	return &Collection{
+		name:  name,
+		nodes: map[string]Node{},
+	}
+}
+
+// Name returns the collection's name.
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// AddNode adds n to the collection, keyed by its ID. Adding a node with an
+// ID already present is a no-op, so concurrent discovery of the same
+// descriptor from multiple manifests is deduplicated automatically.
+func (c *Collection) AddNode(n Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[n.ID()]; ok {
+		return
+	}
+	c.nodes[n.ID()] = n
+}
+
+// Nodes returns all nodes currently in the collection, in no particular
+// order.
+func (c *Collection) Nodes() []Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nodes := make([]Node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
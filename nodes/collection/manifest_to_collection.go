@@ -0,0 +1,248 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Fetcher retrieves the raw bytes backing an OCI descriptor, e.g. from a
+// registry or a local blob store.
+type Fetcher func(ctx context.Context, desc ocispec.Descriptor) ([]byte, error)
+
+// VisitorFunc is invoked as each descriptor is discovered during traversal,
+// letting callers stream-process a large manifest graph instead of waiting
+// for it to be fully materialized into a Collection.
+type VisitorFunc func(ctx context.Context, desc ocispec.Descriptor) error
+
+// TraversalOptions configures LoadFromManifestWithOptions.
+type TraversalOptions struct {
+	// Workers bounds how many Fetcher calls are in flight at once. Defaults
+	// to 4 if zero. Traversal and node bookkeeping themselves are not
+	// bounded by this -- only the (typically network-bound) fetch calls
+	// are -- so this controls concurrent I/O, not goroutine count.
+	Workers int
+
+	// Cache, if set, is consulted before fetching a manifest/index
+	// descriptor and populated after a successful fetch, so repeated Loads
+	// across a session avoid re-fetching unchanged content.
+	Cache Cache
+
+	// Visitor, if set, is called once per discovered descriptor, in
+	// addition to it being added to the Collection.
+	Visitor VisitorFunc
+
+	// ResumePath, if set, persists visited-digest state to this file as
+	// traversal proceeds, and skips digests already recorded there on
+	// startup, so an interrupted large pull can resume where it left off.
+	ResumePath string
+}
+
+// DefaultTraversalOptions returns the TraversalOptions LoadFromManifest uses
+// when no options are given explicitly.
+func DefaultTraversalOptions() TraversalOptions {
+	return TraversalOptions{Workers: 4}
+}
+
+// LoadFromManifest walks the manifest graph rooted at root, adding every
+// discovered descriptor (the manifest itself, its config, and its layers,
+// recursing into any child that is itself a manifest or index) to c as a
+// Node. It uses DefaultTraversalOptions; call LoadFromManifestWithOptions
+// directly to configure concurrency, caching, a visitor, or resume support.
+func LoadFromManifest(ctx context.Context, c *Collection, fetch Fetcher, root ocispec.Descriptor) error {
+	return LoadFromManifestWithOptions(ctx, c, fetch, root, DefaultTraversalOptions())
+}
+
+// LoadFromManifestWithOptions is LoadFromManifest with a configurable
+// worker pool, content cache, discovery visitor, and resume support. Nodes
+// are deduplicated by digest, so a descriptor referenced by more than one
+// parent is only fetched and visited once.
+func LoadFromManifestWithOptions(ctx context.Context, c *Collection, fetch Fetcher, root ocispec.Descriptor, opts TraversalOptions) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 4
+	}
+
+	visited := map[string]bool{}
+	if opts.ResumePath != "" {
+		existing, err := loadVisitedState(opts.ResumePath)
+		if err != nil {
+			return err
+		}
+		visited = existing
+	}
+
+	resumeWriter, err := newVisitedStateWriter(opts.ResumePath)
+	if err != nil {
+		return err
+	}
+	defer resumeWriter.Close()
+
+	t := &traversal{
+		ctx:     ctx,
+		c:       c,
+		fetch:   fetch,
+		opts:    opts,
+		visited: visited,
+		resume:  resumeWriter,
+		sem:     make(chan struct{}, workers),
+		errCh:   make(chan error, 1),
+	}
+
+	t.wg.Add(1)
+	t.spawn(root)
+	t.wg.Wait()
+
+	close(t.errCh)
+	for err := range t.errCh {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// traversal holds the shared state for one LoadFromManifestWithOptions run:
+// the dedup set, the resume-state writer, and a bounded worker pool.
+type traversal struct {
+	ctx   context.Context
+	c     *Collection
+	fetch Fetcher
+	opts  TraversalOptions
+
+	mu      sync.Mutex
+	visited map[string]bool
+	resume  *visitedStateWriter
+
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+
+	errCh chan error
+}
+
+func (t *traversal) fail(err error) {
+	t.once.Do(func() { t.errCh <- err })
+}
+
+// spawn schedules desc for processing in its own goroutine. The caller must
+// have already called t.wg.Add(1). Goroutines themselves are not bounded
+// here -- only the fetch calls they may issue are, via t.sem in process --
+// since a goroutine blocked waiting for *its own child* to get a pool slot
+// would otherwise never free the slot it's already holding, deadlocking the
+// whole traversal once Workers goroutines are all one level deep.
+func (t *traversal) spawn(desc ocispec.Descriptor) {
+	go func() {
+		defer t.wg.Done()
+		t.process(desc)
+	}()
+}
+
+func (t *traversal) process(desc ocispec.Descriptor) {
+	select {
+	case <-t.ctx.Done():
+		return
+	default:
+	}
+
+	digest := desc.Digest.String()
+
+	t.mu.Lock()
+	if t.visited[digest] {
+		t.mu.Unlock()
+		return
+	}
+	t.visited[digest] = true
+	t.mu.Unlock()
+
+	if err := t.resume.record(digest); err != nil {
+		t.fail(err)
+		return
+	}
+
+	t.c.AddNode(newDescriptorNode(desc))
+
+	if t.opts.Visitor != nil {
+		if err := t.opts.Visitor(t.ctx, desc); err != nil {
+			t.fail(err)
+			return
+		}
+	}
+
+	if !isManifestType(desc.MediaType) {
+		return
+	}
+
+	data, ok := t.cacheGet(digest)
+	if !ok {
+		// Only the actual fetch is bounded by the worker pool; acquiring and
+		// releasing the slot around just this call (rather than around
+		// spawn, see spawn's comment) means a goroutine waiting here never
+		// holds a slot of its own, so the pool can't deadlock on itself.
+		t.sem <- struct{}{}
+		fetched, err := t.fetch(t.ctx, desc)
+		<-t.sem
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		data = fetched
+		t.cachePut(digest, data)
+	}
+
+	// A manifest lists its children under "config" and "layers", an index
+	// under "manifests"; decoding all three fields expands either kind.
+	var manifest struct {
+		Config    ocispec.Descriptor   `json:"config"`
+		Layers    []ocispec.Descriptor `json:"layers"`
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.fail(err)
+		return
+	}
+
+	children := make([]ocispec.Descriptor, 0, len(manifest.Manifests)+len(manifest.Layers)+1)
+	// An index has no config; skip the zero-value descriptor it decodes to.
+	if manifest.Config.Digest != "" {
+		children = append(children, manifest.Config)
+	}
+	children = append(children, manifest.Manifests...)
+	children = append(children, manifest.Layers...)
+
+	for _, child := range children {
+		t.wg.Add(1)
+		t.spawn(child)
+	}
+}
+
+func (t *traversal) cacheGet(digest string) ([]byte, bool) {
+	if t.opts.Cache == nil {
+		return nil, false
+	}
+	return t.opts.Cache.Get(digest)
+}
+
+func (t *traversal) cachePut(digest string, data []byte) {
+	if t.opts.Cache == nil {
+		return
+	}
+	t.opts.Cache.Put(digest, data)
+}
+
+// isManifestType reports whether mediaType identifies an OCI/Docker
+// manifest or index, i.e. content that LoadFromManifest should fetch and
+// expand rather than treat as a leaf node.
+func isManifestType(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeImageManifest,
+		ocispec.MediaTypeImageIndex,
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json":
+		return true
+	default:
+		return false
+	}
+}
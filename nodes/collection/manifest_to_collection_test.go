@@ -2,7 +2,11 @@ package collection
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/opencontainers/go-digest"
@@ -95,3 +99,114 @@ func testFetcher(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
 	}
 	return []byte{}, nil
 }
+
+// sharedLayer is referenced by both sub-manifests below, so a correct
+// traversal must add it to the collection exactly once; being a leaf, it is
+// never fetched.
+var sharedLayer = ocispec.Descriptor{
+	MediaType: "application/json",
+	Digest:    digest.Digest("sha256:0c7f453f9f3463d41110402f70e913ef7d850986a231276c0065ff958639b976"),
+	Size:      76,
+}
+
+func manifestBytes(t *testing.T, config ocispec.Descriptor, layers ...ocispec.Descriptor) []byte {
+	t.Helper()
+	b, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	require.NoError(t, err)
+	return b
+}
+
+func TestLoadFromManifestWithOptions_DedupesSharedChildren(t *testing.T) {
+	configA := ocispec.Descriptor{MediaType: "application/vnd.uor.config.v1+json", Digest: digest.Digest("sha256:aaaa")}
+	configB := ocispec.Descriptor{MediaType: "application/vnd.uor.config.v1+json", Digest: digest.Digest("sha256:bbbb")}
+	subA := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest("sha256:1111")}
+	subB := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest("sha256:2222")}
+	rootDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.Digest("sha256:0000")}
+
+	var fetchCount int32
+	fetchedDigests := sync.Map{}
+	fetch := func(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
+		atomic.AddInt32(&fetchCount, 1)
+		fetchedDigests.Store(desc.Digest.String(), true)
+		switch desc.Digest.String() {
+		case rootDesc.Digest.String():
+			return manifestBytes(t, ocispec.Descriptor{}, subA, subB)
+		case subA.Digest.String():
+			return manifestBytes(t, configA, sharedLayer)
+		case subB.Digest.String():
+			return manifestBytes(t, configB, sharedLayer)
+		default:
+			return []byte{}, nil
+		}
+	}
+
+	c := New("dedup-test")
+	err := LoadFromManifestWithOptions(context.Background(), c, fetch, rootDesc, TraversalOptions{Workers: 4})
+	require.NoError(t, err)
+
+	// root, subA, subB, configA, configB, sharedLayer: 6 unique nodes even
+	// though sharedLayer is referenced twice.
+	require.Len(t, c.Nodes(), 6)
+
+	count := 0
+	fetchedDigests.Range(func(key, _ interface{}) bool {
+		if key.(string) == sharedLayer.Digest.String() {
+			count++
+		}
+		return true
+	})
+	require.Equal(t, 0, count, "sharedLayer is a leaf and should never be fetched")
+}
+
+func TestLoadFromManifestWithOptions_BoundedParallelism(t *testing.T) {
+	const workers = 2
+	const subManifests = 6
+
+	var children []ocispec.Descriptor
+	for i := 0; i < subManifests; i++ {
+		children = append(children, ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    digest.Digest(fmt.Sprintf("sha256:child%d", i)),
+		})
+	}
+	rootDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.Digest("sha256:root")}
+
+	var inFlight int32
+	var maxInFlight int32
+	release := make(chan struct{})
+
+	fetch := func(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
+		if desc.Digest.String() == rootDesc.Digest.String() {
+			return manifestBytes(t, ocispec.Descriptor{}, children...)
+		}
+
+		cur := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, cur) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&inFlight, -1)
+		return manifestBytes(t, ocispec.Descriptor{MediaType: "application/json", Digest: desc.Digest})
+	}
+
+	c := New("parallelism-test")
+	done := make(chan error, 1)
+	go func() {
+		done <- LoadFromManifestWithOptions(context.Background(), c, fetch, rootDesc, TraversalOptions{Workers: workers})
+	}()
+
+	// Release the gated fetches gradually; at no point should more than
+	// `workers` be in flight at once.
+	for i := 0; i < subManifests; i++ {
+		release <- struct{}{}
+	}
+	require.NoError(t, <-done)
+	require.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(workers))
+}
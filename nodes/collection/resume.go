@@ -0,0 +1,62 @@
+package collection
+
+import (
+	"bufio"
+	"os"
+)
+
+// loadVisitedState reads the set of previously visited digests from path,
+// one per line, so an interrupted traversal can skip work it already did.
+func loadVisitedState(path string) (map[string]bool, error) {
+	visited := map[string]bool{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return visited, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			visited[line] = true
+		}
+	}
+	return visited, scanner.Err()
+}
+
+// visitedStateWriter appends newly visited digests to a resume-state file as
+// they're discovered, so a killed process can resume from the last digest
+// it recorded rather than restarting the whole traversal.
+type visitedStateWriter struct {
+	f *os.File
+}
+
+func newVisitedStateWriter(path string) (*visitedStateWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &visitedStateWriter{f: f}, nil
+}
+
+func (w *visitedStateWriter) record(digest string) error {
+	if w == nil {
+		return nil
+	}
+	_, err := w.f.WriteString(digest + "\n")
+	return err
+}
+
+func (w *visitedStateWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.f.Close()
+}
@@ -0,0 +1,60 @@
+package registryauth
+
+import (
+	"strings"
+
+	ecrlogin "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	acrcredhelper "github.com/chrismellard/docker-credential-acr-env/pkg/credhelper"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// ecrRegistrySuffix matches ECR registry hostnames, e.g.
+// "123456789012.dkr.ecr.us-east-1.amazonaws.com".
+const ecrRegistrySuffix = ".dkr.ecr."
+
+// ecrSource resolves credentials for Amazon ECR registries using the
+// standard AWS credential chain (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY,
+// shared config/profile, or the EC2/ECS instance metadata service).
+type ecrSource struct{}
+
+func (ecrSource) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if !strings.Contains(registry, ecrRegistrySuffix) {
+		return authn.Anonymous, nil
+	}
+
+	helper := ecrlogin.NewECRHelper()
+	user, secret, err := helper.Get(registry)
+	if err != nil {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username: user,
+		Password: secret,
+	}), nil
+}
+
+// acrRegistrySuffix matches Azure Container Registry hostnames, e.g.
+// "myregistry.azurecr.io".
+const acrRegistrySuffix = ".azurecr.io"
+
+// acrSource resolves credentials for Azure Container Registry using Azure
+// Managed Service Identity (or az CLI credentials when run interactively).
+type acrSource struct{}
+
+func (acrSource) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if !strings.HasSuffix(registry, acrRegistrySuffix) {
+		return authn.Anonymous, nil
+	}
+
+	helper := acrcredhelper.NewACRCredentialsHelper()
+	user, secret, err := helper.Get(registry)
+	if err != nil {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username: user,
+		Password: secret,
+	}), nil
+}
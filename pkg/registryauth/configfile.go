@@ -0,0 +1,78 @@
+package registryauth
+
+import (
+	"os"
+	"sync"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/credentials"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// configFileSource resolves credentials from explicit docker config JSON
+// files, in the order given, using the same lookup as the original
+// (pre-refactor) buildKeychain in package cli, except that a config file
+// that does not exist now yields anonymous access instead of an error.
+func configFileSource(paths []string) source {
+	var mu sync.Mutex
+	var keychains []authn.Keychain
+	for _, config := range paths {
+		config := config
+		keychains = append(keychains, sourceFunc(func(target authn.Resource) (authn.Authenticator, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, err := os.Stat(config); err != nil {
+				if os.IsNotExist(err) {
+					return authn.Anonymous, nil
+				}
+				return nil, err
+			}
+
+			cf := configfile.New(config)
+			file, err := os.Open(config)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			if err := cf.LoadFromReader(file); err != nil {
+				return nil, err
+			}
+
+			if !cf.ContainsAuth() {
+				cf.CredentialsStore = credentials.DetectDefaultStore(cf.CredentialsStore)
+			}
+
+			// See:
+			// https://github.com/google/ko/issues/90
+			// https://github.com/moby/moby/blob/fc01c2b481097a6057bec3cd1ab2d7b4488c50c4/registry/config.go#L397-L404
+			var cfg, empty types.AuthConfig
+			for _, key := range []string{target.String(), target.RegistryStr()} {
+				if key == name.DefaultRegistry {
+					key = authn.DefaultAuthKey
+				}
+				cfg, err = cf.GetAuthConfig(key)
+				if err != nil {
+					return nil, err
+				}
+				if cfg != empty {
+					break
+				}
+			}
+			if cfg == empty {
+				return authn.Anonymous, nil
+			}
+
+			return authn.FromConfig(authn.AuthConfig{
+				Username:      cfg.Username,
+				Password:      cfg.Password,
+				Auth:          cfg.Auth,
+				IdentityToken: cfg.IdentityToken,
+				RegistryToken: cfg.RegistryToken,
+			}), nil
+		}))
+	}
+	return sourceFunc(authn.NewMultiKeychain(keychains...).Resolve)
+}
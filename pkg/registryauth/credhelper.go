@@ -0,0 +1,56 @@
+package registryauth
+
+import (
+	"os/exec"
+	"runtime"
+
+	credhelperclient "github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// platformCredHelpers lists the native OS credential helper binaries to
+// probe for, in priority order, before falling back to the generic "pass"
+// helper used on most other Unix desktops.
+func platformCredHelpers() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"docker-credential-osxkeychain", "docker-credential-pass"}
+	case "windows":
+		return []string{"docker-credential-wincred"}
+	default:
+		return []string{"docker-credential-secretservice", "docker-credential-pass"}
+	}
+}
+
+// credentialHelperSource resolves credentials via the first installed native
+// OS credential helper that returns an entry for the registry
+// (docker-credential-osxkeychain,
+// docker-credential-secretservice, docker-credential-wincred,
+// docker-credential-pass), invoked over the standard credential-helper
+// protocol (docker-credential-helpers/client).
+type credentialHelperSource struct{}
+
+func (credentialHelperSource) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	for _, name := range platformCredHelpers() {
+		if _, err := exec.LookPath(name); err != nil {
+			continue
+		}
+
+		program := credhelperclient.NewShellProgramFunc(name)
+		creds, err := credhelperclient.Get(program, target.RegistryStr())
+		if err != nil {
+			// Helper present but has no entry for this registry; try the
+			// next helper rather than failing resolution outright.
+			continue
+		}
+
+		if creds.Username == "<token>" {
+			return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+		}
+		return authn.FromConfig(authn.AuthConfig{
+			Username: creds.Username,
+			Password: creds.Secret,
+		}), nil
+	}
+	return authn.Anonymous, nil
+}